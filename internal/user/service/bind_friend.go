@@ -17,24 +17,35 @@ limitations under the License.
 package service
 
 import (
+	"errors"
 	"fmt"
 
+	userDB "github.com/west2-online/fzuhelper-server/pkg/db/user"
 	"github.com/west2-online/fzuhelper-server/pkg/logger"
+	"github.com/west2-online/fzuhelper-server/pkg/utils"
 )
 
+// BindInvitation 以邀请码的 DB 记录为唯一事实来源：owner、有效期、剩余次数全部来自
+// invitation_code 表，code_mapping 缓存只是旁路，次数用尽后由 outbox dispatcher 或待处理流程清理，不参与校验。
 func (s *UserService) BindInvitation(stuId, code string) error {
-	mapKey := fmt.Sprintf("code_mapping:%s", code)
-	exist := s.cache.IsKeyExist(s.ctx, mapKey)
-	if !exist {
-		return fmt.Errorf("service.BindInvitation: Invalid InvitationCode")
+	codeId, err := utils.DecodeInvitationId(code)
+	if err != nil {
+		return fmt.Errorf("service.BindInvitation: %w", err)
 	}
-	friendId, err := s.cache.User.GetCodeStuIdMappingCache(s.ctx, mapKey)
+	invitation, err := s.db.User.GetInvitationCodeById(s.ctx, codeId)
 	if err != nil {
-		return fmt.Errorf("service.GetCodeStuIdMappingCode: %w", err)
+		return fmt.Errorf("service.GetInvitationCodeById: %w", err)
+	}
+	if !invitation.IsAvailable() {
+		return fmt.Errorf("service.BindInvitation: Invalid InvitationCode")
 	}
+	friendId := invitation.OwnerStuId
 	if friendId == stuId {
 		return fmt.Errorf("service.BindInvitation: cannot add yourself as friend")
 	}
+
+	mapKey := fmt.Sprintf("code_mapping:%s", code)
+
 	// 查找是否关系已经存在
 	ok, _, err := s.db.User.GetRelationByUserId(s.ctx, stuId, friendId)
 	if err != nil {
@@ -43,16 +54,38 @@ func (s *UserService) BindInvitation(stuId, code string) error {
 	if ok {
 		return fmt.Errorf("service.BindInvitation: RelationShip Already Exist")
 	}
-	err = s.db.User.CreateRelation(s.ctx, stuId, friendId)
-	if err != nil {
-		return fmt.Errorf("service.CreateRelation: %w", err)
-	}
-	go func() {
-		// 目前绑定成功插入双向关系
-		err = s.cache.User.SetUserFriendCache(s.ctx, friendId, stuId)
+
+	// 关系/好友申请的落地和邀请码使用次数的扣减都在同一个事务里完成，只有在事务成功之后
+	// 才会扣减，这样事务失败（比如屏蔽、DB 抖动）都不会白白烧掉一次使用次数。
+	var remaining int64
+	if invitation.AutoAccept {
+		remaining, err = s.db.User.CreateRelationWithOutboxAndConsume(s.ctx, stuId, friendId, codeId, mapKey)
 		if err != nil {
-			logger.Errorf("service. SetUserFriendCache: %v", err)
+			if errors.Is(err, userDB.ErrBlocked) {
+				return fmt.Errorf("service.BindInvitation: blocked")
+			}
+			return fmt.Errorf("service.CreateRelationWithOutboxAndConsume: %w", err)
+		}
+	} else {
+		// 邀请码没有开启自动通过，持有者只能发起一条待处理的好友申请，由邀请码的主人 Accept 之后才建立关系
+		_, remaining, err = s.db.User.CreateFriendRequestAndConsume(s.ctx, stuId, friendId, "", codeId)
+		if err != nil {
+			if errors.Is(err, userDB.ErrBlocked) {
+				return fmt.Errorf("service.BindInvitation: blocked")
+			}
+			return fmt.Errorf("service.CreateFriendRequestAndConsume: %w", err)
+		}
+		if remaining == 0 {
+			if err = s.cache.User.RemoveCodeStuIdMappingCache(s.ctx, mapKey); err != nil {
+				logger.Errorf("service.RemoveCodeStuIdMappingCache: %v", err)
+			}
 		}
-	}()
+	}
+
+	if remaining == 0 {
+		if err = s.db.User.DeleteInvitationCode(s.ctx, codeId); err != nil {
+			logger.Errorf("service.DeleteInvitationCode: %v", err)
+		}
+	}
 	return nil
 }
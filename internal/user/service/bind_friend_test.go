@@ -19,7 +19,6 @@ package service
 import (
 	"context"
 	"fmt"
-	"strings"
 	"testing"
 	"time"
 
@@ -43,53 +42,77 @@ func TestUserService_BindInvitation(t *testing.T) {
 		expectingError    bool
 		expectingErrorMsg string
 
-		cacheExist        bool
-		cacheGetError     error
-		cacheFriendId     string
-		dbRelationExist   bool
-		dbRelationError   error
-		dbCreateError     error
-		userConfined      bool
-		targetConfined    bool
-		userConfinedError error
-		targetConfinedErr error
-
-		// 添加 goroutine 相关的字段
-		goroutineMockCacheExist bool
-		targetCacheExist        bool
-		goroutineError          bool
+		decodeIdError    error
+		invitation       *dbmodel.InvitationCode
+		getInvitationErr error
+		dbRelationExist  bool
+		dbRelationError  error
+		createError      error
+
+		// 自动通过的邀请码会落一条事务性 outbox 记录，非自动通过的邀请码只会落一条待处理的好友申请
+		expectOutboxWrite        bool
+		expectFriendRequestWrite bool
+		expectRemoveMappingCache bool
 	}
 	stuId := "102300217"
 	friendId := "102300218"
 	code := "ABCDEF"
+	codeId := int64(1)
+	autoAcceptInvitation := &dbmodel.InvitationCode{
+		ID:            codeId,
+		OwnerStuId:    friendId,
+		RemainingUses: 1,
+		ExpiresAt:     time.Now().Add(time.Hour),
+		AutoAccept:    true,
+	}
+	pendingInvitation := &dbmodel.InvitationCode{
+		ID:            codeId,
+		OwnerStuId:    friendId,
+		RemainingUses: 1,
+		ExpiresAt:     time.Now().Add(time.Hour),
+		AutoAccept:    false,
+	}
+	expiredInvitation := &dbmodel.InvitationCode{
+		ID:            codeId,
+		OwnerStuId:    friendId,
+		RemainingUses: 1,
+		ExpiresAt:     time.Now().Add(-time.Hour),
+		AutoAccept:    true,
+	}
 
 	testCases := []testCase{
 		{
-			name:              "cache not exist",
+			name:              "invalid invitation code format",
 			expectingError:    true,
-			expectingErrorMsg: "service.BindInvitation: Invalid InvitationCode",
-			cacheExist:        false,
+			expectingErrorMsg: "service.BindInvitation:",
+			decodeIdError:     fmt.Errorf("invalid invitation code"),
 		},
 		{
-			name:              "cache get error",
+			name:              "get invitation error",
 			expectingError:    true,
-			expectingErrorMsg: "service.GetCodeStuIdMappingCode:",
-			cacheExist:        true,
-			cacheGetError:     fmt.Errorf("internal service error"),
+			expectingErrorMsg: "service.GetInvitationCodeById:",
+			getInvitationErr:  gorm.ErrInvalidData,
+		},
+		{
+			name:              "invitation expired or exhausted",
+			expectingError:    true,
+			expectingErrorMsg: "service.BindInvitation: Invalid InvitationCode",
+			invitation:        expiredInvitation,
 		},
 		{
 			name:              "add self as friend",
 			expectingError:    true,
 			expectingErrorMsg: "service.BindInvitation: cannot add yourself as friend",
-			cacheExist:        true,
-			cacheFriendId:     stuId,
+			invitation: &dbmodel.InvitationCode{
+				ID: codeId, OwnerStuId: stuId, RemainingUses: 1,
+				ExpiresAt: time.Now().Add(time.Hour), AutoAccept: true,
+			},
 		},
 		{
 			name:              "relation already exist",
 			expectingError:    true,
 			expectingErrorMsg: "service.BindInvitation: RelationShip Already Exist",
-			cacheExist:        true,
-			cacheFriendId:     friendId,
+			invitation:        autoAcceptInvitation,
 			dbRelationExist:   true,
 			dbRelationError:   nil,
 		},
@@ -97,62 +120,44 @@ func TestUserService_BindInvitation(t *testing.T) {
 			name:              "db relation check error",
 			expectingError:    true,
 			expectingErrorMsg: "service.GetRelationByUserId:",
-			cacheExist:        true,
-			cacheFriendId:     friendId,
+			invitation:        autoAcceptInvitation,
 			dbRelationExist:   false,
 			dbRelationError:   gorm.ErrInvalidData,
 		},
 		{
-			name:              "user friend list full",
-			expectingError:    true,
-			expectingErrorMsg: "service.BindInvitation :102300217 friendList is full",
-			cacheExist:        true,
-			cacheFriendId:     friendId,
-			dbRelationExist:   false,
-			dbRelationError:   nil,
-			userConfined:      true,
-		},
-		{
-			name:              "target friend list full",
+			name:              "db create error",
 			expectingError:    true,
-			expectingErrorMsg: "service.BindInvitation :102300218 friendList is full",
-			cacheExist:        true,
-			cacheFriendId:     friendId,
+			expectingErrorMsg: "service.CreateRelationWithOutboxAndConsume:",
+			invitation:        autoAcceptInvitation,
 			dbRelationExist:   false,
 			dbRelationError:   nil,
-			targetConfined:    true,
+			createError:       gorm.ErrInvalidData,
 		},
 		{
-			name:              "user confined check error",
+			name:              "blocked",
 			expectingError:    true,
-			expectingErrorMsg: "service.IsFriendNumsConfined get user friend cache:",
-			cacheExist:        true,
-			cacheFriendId:     friendId,
+			expectingErrorMsg: "service.BindInvitation: blocked",
+			invitation:        autoAcceptInvitation,
 			dbRelationExist:   false,
 			dbRelationError:   nil,
-			userConfinedError: fmt.Errorf("service.IsFriendNumsConfined get user friend cache: cache error"),
+			createError:       userDB.ErrBlocked,
 		},
 		{
-			name:              "db create error",
-			expectingError:    true,
-			expectingErrorMsg: "service.CreateRelation:",
-			cacheExist:        true,
-			cacheFriendId:     friendId,
+			name:              "success auto accept",
+			expectingError:    false,
+			invitation:        autoAcceptInvitation,
 			dbRelationExist:   false,
 			dbRelationError:   nil,
-			dbCreateError:     gorm.ErrInvalidData,
+			expectOutboxWrite: true,
 		},
 		{
-			name:                    "success",
-			expectingError:          false,
-			cacheExist:              true,
-			cacheFriendId:           friendId,
-			dbRelationExist:         false,
-			dbRelationError:         nil,
-			dbCreateError:           nil,
-			goroutineMockCacheExist: true, // goroutine 中检查缓存会返回 true
-			targetCacheExist:        true,
-			goroutineError:          false,
+			name:                     "success pending approval",
+			expectingError:           false,
+			invitation:               pendingInvitation,
+			dbRelationExist:          false,
+			dbRelationError:          nil,
+			expectFriendRequestWrite: true,
+			expectRemoveMappingCache: true,
 		},
 	}
 
@@ -160,8 +165,10 @@ func TestUserService_BindInvitation(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			mockey.PatchConvey(tc.name, t, func() {
-				// 使用 channel 来等待 goroutine 完成
-				goroutineDone := make(chan bool, 1)
+				outboxWritten := false
+				var outboxCodeMappingKey string
+				friendRequestWritten := false
+				mappingCacheRemoved := false
 
 				mockClientSet := &base.ClientSet{
 					SFClient:    new(utils.Snowflake),
@@ -171,65 +178,51 @@ func TestUserService_BindInvitation(t *testing.T) {
 				mockClientSet.CacheClient.User = &user.CacheUser{}
 				userService := NewUserService(context.Background(), "", nil, mockClientSet)
 
-				// Mock 缓存检查 - 主线程和goroutine都可能调用
-				isKeyExistMock := mockey.Mock((*cache.Cache).IsKeyExist)
-				if tc.expectingError {
-					// 错误情况下，goroutine 不会执行，所以只 mock 主逻辑
-					isKeyExistMock.To(func(ctx context.Context, key string) bool {
-						return tc.cacheExist
-					}).Build()
-				} else {
-					// 成功情况下，主逻辑和goroutine都会调用
-					isKeyExistMock.To(func(ctx context.Context, key string) bool {
-						// 根据 key 判断是主逻辑调用还是 goroutine 调用
-						if strings.HasPrefix(key, "code_mapping:") {
-							return tc.cacheExist
-						}
-						// goroutine 中的调用
-						return tc.goroutineMockCacheExist
-					}).Build()
-				}
-
-				mockey.Mock((*user.CacheUser).GetCodeStuIdMappingCache).To(func(ctx context.Context, key string) (string, error) {
-					if tc.cacheGetError != nil {
-						return "", tc.cacheGetError
+				mockey.Mock(utils.DecodeInvitationId).To(func(code string) (int64, error) {
+					if tc.decodeIdError != nil {
+						return 0, tc.decodeIdError
 					}
-					return tc.cacheFriendId, nil
-				}).Build()
-
-				mockey.Mock((*userDB.DBUser).GetRelationByUserId).To(func(ctx context.Context, stuId, friendId string) (bool, *dbmodel.FollowRelation, error) {
-					return tc.dbRelationExist, nil, tc.dbRelationError
+					return codeId, nil
 				}).Build()
 
-				// Mock 好友数量检查
-				mockey.Mock((*UserService).IsFriendNumsConfined).To(func(s *UserService, stuId string) (bool, error) {
-					if stuId == "102300217" {
-						return tc.userConfined, tc.userConfinedError
+				mockey.Mock((*userDB.DBUser).GetInvitationCodeById).To(func(ctx context.Context, id int64) (*dbmodel.InvitationCode, error) {
+					if tc.getInvitationErr != nil {
+						return nil, tc.getInvitationErr
 					}
-					return tc.targetConfined, tc.targetConfinedErr
+					return tc.invitation, nil
 				}).Build()
 
-				mockey.Mock((*userDB.DBUser).CreateRelation).To(func(ctx context.Context, stuId, friendId string) error {
-					return tc.dbCreateError
+				mockey.Mock((*userDB.DBUser).GetRelationByUserId).To(func(ctx context.Context, stuId, friendId string) (bool, *dbmodel.FollowRelation, error) {
+					return tc.dbRelationExist, nil, tc.dbRelationError
 				}).Build()
 
-				// Mock goroutine 中的缓存操作
-				if !tc.expectingError {
-					mockey.Mock((*user.CacheUser).SetUserFriendCache).To(func(ctx context.Context, stuId, friendId string) error {
-						if tc.goroutineError {
-							return fmt.Errorf("cache error")
+				mockey.Mock((*userDB.DBUser).CreateRelationWithOutboxAndConsume).To(
+					func(ctx context.Context, stuId, friendId string, id int64, mapKey string) (int64, error) {
+						if tc.createError != nil {
+							return 0, tc.createError
 						}
-						return nil
+						outboxWritten = true
+						outboxCodeMappingKey = mapKey
+						return 0, nil
 					}).Build()
 
-					mockey.Mock((*user.CacheUser).RemoveCodeStuIdMappingCache).To(func(ctx context.Context, key string) error {
-						if tc.goroutineError {
-							return fmt.Errorf("remove cache error")
+				mockey.Mock((*userDB.DBUser).CreateFriendRequestAndConsume).To(
+					func(ctx context.Context, fromStuId, toStuId, message string, id int64) (*dbmodel.FriendRequest, int64, error) {
+						if tc.createError != nil {
+							return nil, 0, tc.createError
 						}
-						goroutineDone <- true // 标记 goroutine 完成
-						return nil
+						friendRequestWritten = true
+						return &dbmodel.FriendRequest{FromStuId: fromStuId, ToStuId: toStuId}, 0, nil
 					}).Build()
-				}
+
+				mockey.Mock((*user.CacheUser).RemoveCodeStuIdMappingCache).To(func(ctx context.Context, key string) error {
+					mappingCacheRemoved = true
+					return nil
+				}).Build()
+
+				mockey.Mock((*userDB.DBUser).DeleteInvitationCode).To(func(ctx context.Context, id int64) error {
+					return nil
+				}).Build()
 
 				err := userService.BindInvitation(stuId, code)
 
@@ -240,17 +233,14 @@ func TestUserService_BindInvitation(t *testing.T) {
 					}
 				} else {
 					assert.NoError(t, err)
-					// 等待 goroutine 完成（如果有的话）
-					if tc.expectingError == false {
-						select {
-						case <-goroutineDone:
-							// goroutine 完成
-						case <-time.After(100 * time.Millisecond):
-							// 超时，goroutine 可能没有正确执行
-							t.Log("goroutine timeout, but test may still pass")
-						}
-					}
 				}
+
+				assert.Equal(t, tc.expectOutboxWrite, outboxWritten)
+				if tc.expectOutboxWrite {
+					assert.Equal(t, fmt.Sprintf("code_mapping:%s", code), outboxCodeMappingKey)
+				}
+				assert.Equal(t, tc.expectFriendRequestWrite, friendRequestWritten)
+				assert.Equal(t, tc.expectRemoveMappingCache, mappingCacheRemoved)
 			})
 		})
 	}
@@ -0,0 +1,97 @@
+/*
+Copyright 2024 The west2-online Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"fmt"
+
+	"github.com/west2-online/fzuhelper-server/pkg/logger"
+)
+
+// BlockUser 屏蔽 blockedStuId，并清掉双方已有的好友关系与待处理的好友申请，同时让两侧的好友缓存失效。
+func (s *UserService) BlockUser(stuId, blockedStuId string) error {
+	if err := s.db.User.BlockUser(s.ctx, stuId, blockedStuId); err != nil {
+		return fmt.Errorf("service.BlockUser: %w", err)
+	}
+
+	if err := s.cache.User.InvalidateUserBlockCache(s.ctx, stuId); err != nil {
+		logger.Errorf("service.InvalidateUserBlockCache: %v", err)
+	}
+	if err := s.cache.User.RemoveUserFriendCache(s.ctx, stuId, blockedStuId); err != nil {
+		logger.Errorf("service.RemoveUserFriendCache: %v", err)
+	}
+	if err := s.cache.User.RemoveUserFriendCache(s.ctx, blockedStuId, stuId); err != nil {
+		logger.Errorf("service.RemoveUserFriendCache: %v", err)
+	}
+	return nil
+}
+
+// UnblockUser 撤销一条屏蔽记录，不会恢复之前被清掉的好友关系。
+func (s *UserService) UnblockUser(stuId, blockedStuId string) error {
+	if err := s.db.User.UnblockUser(s.ctx, stuId, blockedStuId); err != nil {
+		return fmt.Errorf("service.UnblockUser: %w", err)
+	}
+	if err := s.cache.User.RemoveUserBlockCache(s.ctx, stuId, blockedStuId); err != nil {
+		logger.Errorf("service.RemoveUserBlockCache: %v", err)
+	}
+	return nil
+}
+
+// ListBlocked 返回 stuId 屏蔽的所有学号。
+func (s *UserService) ListBlocked(stuId string) ([]string, error) {
+	blocked, err := s.db.User.ListBlocked(s.ctx, stuId)
+	if err != nil {
+		return nil, fmt.Errorf("service.ListBlocked: %w", err)
+	}
+	return blocked, nil
+}
+
+// IsBlocked 判断 a、b 之间是否存在任意一个方向的屏蔽关系，优先读取缓存，未命中时懒加载 DB 数据。
+func (s *UserService) IsBlocked(a, b string) (bool, error) {
+	blockedByA, err := s.isBlockedBy(a, b)
+	if err != nil {
+		return false, err
+	}
+	if blockedByA {
+		return true, nil
+	}
+	return s.isBlockedBy(b, a)
+}
+
+func (s *UserService) isBlockedBy(stuId, target string) (bool, error) {
+	exist, err := s.cache.User.IsUserBlockCacheExist(s.ctx, stuId)
+	if err != nil {
+		return false, fmt.Errorf("service.IsUserBlockCacheExist: %w", err)
+	}
+	if !exist {
+		blocked, err := s.db.User.ListBlocked(s.ctx, stuId)
+		if err != nil {
+			return false, fmt.Errorf("service.ListBlocked: %w", err)
+		}
+		if len(blocked) == 0 {
+			if err = s.cache.User.MarkUserBlockCacheEmpty(s.ctx, stuId); err != nil {
+				logger.Errorf("service.MarkUserBlockCacheEmpty: %v", err)
+			}
+		}
+		for _, blockedStuId := range blocked {
+			if err = s.cache.User.SetUserBlockCache(s.ctx, stuId, blockedStuId); err != nil {
+				logger.Errorf("service.SetUserBlockCache: %v", err)
+			}
+		}
+	}
+	return s.cache.User.IsBlockedInCache(s.ctx, stuId, target)
+}
@@ -0,0 +1,318 @@
+/*
+Copyright 2024 The west2-online Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bytedance/mockey"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+
+	"github.com/west2-online/fzuhelper-server/pkg/base"
+	"github.com/west2-online/fzuhelper-server/pkg/cache"
+	"github.com/west2-online/fzuhelper-server/pkg/cache/user"
+	"github.com/west2-online/fzuhelper-server/pkg/db"
+	userDB "github.com/west2-online/fzuhelper-server/pkg/db/user"
+	"github.com/west2-online/fzuhelper-server/pkg/utils"
+)
+
+func newMockUserServiceForBlock() *UserService {
+	mockClientSet := &base.ClientSet{
+		SFClient:    new(utils.Snowflake),
+		DBClient:    new(db.Database),
+		CacheClient: new(cache.Cache),
+	}
+	mockClientSet.CacheClient.User = &user.CacheUser{}
+	return NewUserService(context.Background(), "", nil, mockClientSet)
+}
+
+func TestUserService_BlockUser(t *testing.T) {
+	type testCase struct {
+		name string
+
+		expectingError    bool
+		expectingErrorMsg string
+
+		dbError error
+	}
+	stuId := "102300217"
+	blockedStuId := "102300218"
+
+	testCases := []testCase{
+		{
+			name:              "db error",
+			expectingError:    true,
+			expectingErrorMsg: "service.BlockUser:",
+			dbError:           gorm.ErrInvalidData,
+		},
+		{
+			name:           "success",
+			expectingError: false,
+		},
+	}
+
+	defer mockey.UnPatchAll()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockey.PatchConvey(tc.name, t, func() {
+				userService := newMockUserServiceForBlock()
+
+				mockey.Mock((*userDB.DBUser).BlockUser).To(func(ctx context.Context, stuId, blockedStuId string) error {
+					return tc.dbError
+				}).Build()
+				mockey.Mock((*user.CacheUser).InvalidateUserBlockCache).To(func(ctx context.Context, stuId string) error {
+					return nil
+				}).Build()
+				mockey.Mock((*user.CacheUser).RemoveUserFriendCache).To(func(ctx context.Context, stuId, friendId string) error {
+					return nil
+				}).Build()
+
+				err := userService.BlockUser(stuId, blockedStuId)
+
+				if tc.expectingError {
+					assert.Error(t, err)
+					if tc.expectingErrorMsg != "" {
+						assert.Contains(t, err.Error(), tc.expectingErrorMsg)
+					}
+				} else {
+					assert.NoError(t, err)
+				}
+			})
+		})
+	}
+}
+
+func TestUserService_UnblockUser(t *testing.T) {
+	type testCase struct {
+		name string
+
+		expectingError    bool
+		expectingErrorMsg string
+
+		dbError error
+	}
+	stuId := "102300217"
+	blockedStuId := "102300218"
+
+	testCases := []testCase{
+		{
+			name:              "db error",
+			expectingError:    true,
+			expectingErrorMsg: "service.UnblockUser:",
+			dbError:           gorm.ErrInvalidData,
+		},
+		{
+			name:           "success",
+			expectingError: false,
+		},
+	}
+
+	defer mockey.UnPatchAll()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockey.PatchConvey(tc.name, t, func() {
+				userService := newMockUserServiceForBlock()
+
+				mockey.Mock((*userDB.DBUser).UnblockUser).To(func(ctx context.Context, stuId, blockedStuId string) error {
+					return tc.dbError
+				}).Build()
+				mockey.Mock((*user.CacheUser).RemoveUserBlockCache).To(func(ctx context.Context, stuId, blockedStuId string) error {
+					return nil
+				}).Build()
+
+				err := userService.UnblockUser(stuId, blockedStuId)
+
+				if tc.expectingError {
+					assert.Error(t, err)
+					if tc.expectingErrorMsg != "" {
+						assert.Contains(t, err.Error(), tc.expectingErrorMsg)
+					}
+				} else {
+					assert.NoError(t, err)
+				}
+			})
+		})
+	}
+}
+
+func TestUserService_ListBlocked(t *testing.T) {
+	type testCase struct {
+		name string
+
+		expectingError    bool
+		expectingErrorMsg string
+
+		dbBlocked []string
+		dbError   error
+	}
+	stuId := "102300217"
+
+	testCases := []testCase{
+		{
+			name:              "db error",
+			expectingError:    true,
+			expectingErrorMsg: "service.ListBlocked:",
+			dbError:           gorm.ErrInvalidData,
+		},
+		{
+			name:           "success",
+			expectingError: false,
+			dbBlocked:      []string{"102300218", "102300219"},
+		},
+	}
+
+	defer mockey.UnPatchAll()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockey.PatchConvey(tc.name, t, func() {
+				userService := newMockUserServiceForBlock()
+
+				mockey.Mock((*userDB.DBUser).ListBlocked).To(func(ctx context.Context, stuId string) ([]string, error) {
+					return tc.dbBlocked, tc.dbError
+				}).Build()
+
+				blocked, err := userService.ListBlocked(stuId)
+
+				if tc.expectingError {
+					assert.Error(t, err)
+					if tc.expectingErrorMsg != "" {
+						assert.Contains(t, err.Error(), tc.expectingErrorMsg)
+					}
+				} else {
+					assert.NoError(t, err)
+					assert.Equal(t, tc.dbBlocked, blocked)
+				}
+			})
+		})
+	}
+}
+
+func TestUserService_IsBlocked(t *testing.T) {
+	type testCase struct {
+		name string
+
+		expectingError bool
+		expectingValue bool
+
+		aCacheExist bool
+		aBlockedSet []string
+		aListErr    error
+		aInCache    bool
+
+		bCacheExist bool
+		bBlockedSet []string
+		bListErr    error
+		bInCache    bool
+
+		expectMarkEmpty bool
+	}
+	a := "102300217"
+	b := "102300218"
+
+	testCases := []testCase{
+		{
+			name:           "blocked by a, cache hit",
+			expectingValue: true,
+			aCacheExist:    true,
+			aInCache:       true,
+		},
+		{
+			name:           "blocked by b, cache hit",
+			expectingValue: true,
+			aCacheExist:    true,
+			aInCache:       false,
+			bCacheExist:    true,
+			bInCache:       true,
+		},
+		{
+			name:           "not blocked, cache hit both sides",
+			expectingValue: false,
+			aCacheExist:    true,
+			bCacheExist:    true,
+		},
+		{
+			name:           "cache miss lazy loads db and finds a block",
+			expectingValue: true,
+			aCacheExist:    false,
+			aBlockedSet:    []string{b},
+			aInCache:       true,
+		},
+		{
+			name:            "cache miss with nobody blocked still warms the cache",
+			expectingValue:  false,
+			aCacheExist:     false,
+			bCacheExist:     false,
+			expectMarkEmpty: true,
+		},
+		{
+			name:           "db list error on cache miss surfaces",
+			expectingError: true,
+			aCacheExist:    false,
+			aListErr:       gorm.ErrInvalidData,
+		},
+	}
+
+	defer mockey.UnPatchAll()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockey.PatchConvey(tc.name, t, func() {
+				userService := newMockUserServiceForBlock()
+				markedEmpty := make(map[string]bool)
+
+				mockey.Mock((*user.CacheUser).IsUserBlockCacheExist).To(func(ctx context.Context, stuId string) (bool, error) {
+					if stuId == a {
+						return tc.aCacheExist, nil
+					}
+					return tc.bCacheExist, nil
+				}).Build()
+				mockey.Mock((*userDB.DBUser).ListBlocked).To(func(ctx context.Context, stuId string) ([]string, error) {
+					if stuId == a {
+						return tc.aBlockedSet, tc.aListErr
+					}
+					return tc.bBlockedSet, tc.bListErr
+				}).Build()
+				mockey.Mock((*user.CacheUser).SetUserBlockCache).To(func(ctx context.Context, stuId, blockedStuId string) error {
+					return nil
+				}).Build()
+				mockey.Mock((*user.CacheUser).MarkUserBlockCacheEmpty).To(func(ctx context.Context, stuId string) error {
+					markedEmpty[stuId] = true
+					return nil
+				}).Build()
+				mockey.Mock((*user.CacheUser).IsBlockedInCache).To(func(ctx context.Context, stuId, target string) (bool, error) {
+					if stuId == a {
+						return tc.aInCache, nil
+					}
+					return tc.bInCache, nil
+				}).Build()
+
+				blocked, err := userService.IsBlocked(a, b)
+
+				if tc.expectingError {
+					assert.Error(t, err)
+				} else {
+					assert.NoError(t, err)
+					assert.Equal(t, tc.expectingValue, blocked)
+				}
+				if tc.expectMarkEmpty {
+					assert.True(t, markedEmpty[a])
+				}
+			})
+		})
+	}
+}
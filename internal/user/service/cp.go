@@ -0,0 +1,162 @@
+/*
+Copyright 2024 The west2-online Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	userDB "github.com/west2-online/fzuhelper-server/pkg/db/user"
+	"github.com/west2-online/fzuhelper-server/pkg/logger"
+)
+
+// CPInfo 是 GetCP 返回给调用方的视图，在原始 cp_relation 记录之上派生出展示用的字段。
+type CPInfo struct {
+	PartnerStuId    string
+	BoundAt         time.Time
+	DaysTogether    int
+	NextMonthlyAnni time.Time
+	NextYearlyAnni  time.Time
+	Level           string
+}
+
+// InviteCP 向一个已有好友关系的学号发起 CP 邀请，邀请前要求双方都没有处于 invited/accepted 状态的 CP。
+// “至多一个生效中的 CP”的检查和邀请的落地在同一个事务里完成，避免两次并发邀请都通过检查后同时插入。
+func (s *UserService) InviteCP(stuId, friendId string) error {
+	ok, _, err := s.db.User.GetRelationByUserId(s.ctx, stuId, friendId)
+	if err != nil {
+		return fmt.Errorf("service.GetRelationByUserId: %w", err)
+	}
+	if !ok {
+		return fmt.Errorf("service.InviteCP: not friends")
+	}
+
+	if _, err = s.db.User.CreateCPInviteIfNoneActive(s.ctx, stuId, friendId); err != nil {
+		if errors.Is(err, userDB.ErrActiveCPExists) {
+			return fmt.Errorf("service.InviteCP: %s or %s already has an active CP", stuId, friendId)
+		}
+		return fmt.Errorf("service.CreateCPInviteIfNoneActive: %w", err)
+	}
+	return nil
+}
+
+// AcceptCP 由受邀方调用，接受一段还处于 invited 状态的 CP 邀请，并缓存双方的伴侣关系。
+func (s *UserService) AcceptCP(stuId string) error {
+	cp, err := s.db.User.GetActiveCPRelation(s.ctx, stuId)
+	if err != nil {
+		return fmt.Errorf("service.GetActiveCPRelation: %w", err)
+	}
+	if cp == nil || cp.PartnerStuId != stuId {
+		return fmt.Errorf("service.AcceptCP: no pending invitation for %s", stuId)
+	}
+
+	if err = s.db.User.AcceptCPInvite(s.ctx, cp.ID); err != nil {
+		return fmt.Errorf("service.AcceptCPInvite: %w", err)
+	}
+
+	if err = s.cache.User.SetCPCache(s.ctx, cp.StuId, cp.PartnerStuId); err != nil {
+		logger.Errorf("service.SetCPCache: %v", err)
+	}
+	if err = s.cache.User.SetCPCache(s.ctx, cp.PartnerStuId, cp.StuId); err != nil {
+		logger.Errorf("service.SetCPCache: %v", err)
+	}
+	return nil
+}
+
+// CancelCP 撤回一段还没被 Accept 的 CP 邀请，可以由邀请的发起方或受邀方调用。
+func (s *UserService) CancelCP(stuId string) error {
+	cp, err := s.db.User.GetActiveCPRelation(s.ctx, stuId)
+	if err != nil {
+		return fmt.Errorf("service.GetActiveCPRelation: %w", err)
+	}
+	if cp == nil {
+		return fmt.Errorf("service.CancelCP: no active CP for %s", stuId)
+	}
+	if err = s.db.User.CancelCPInvite(s.ctx, cp.ID); err != nil {
+		return fmt.Errorf("service.CancelCPInvite: %w", err)
+	}
+	return nil
+}
+
+// BreakCP 解除 stuId 当前已经生效的 CP，只清掉 CP 状态和缓存，不会删除底层的好友关系。
+func (s *UserService) BreakCP(stuId string) error {
+	cp, err := s.db.User.GetActiveCPRelation(s.ctx, stuId)
+	if err != nil {
+		return fmt.Errorf("service.GetActiveCPRelation: %w", err)
+	}
+	if cp == nil {
+		return fmt.Errorf("service.BreakCP: no active CP for %s", stuId)
+	}
+	if err = s.db.User.BreakCPRelation(s.ctx, cp.ID); err != nil {
+		return fmt.Errorf("service.BreakCPRelation: %w", err)
+	}
+
+	if err = s.cache.User.RemoveCPCache(s.ctx, cp.StuId); err != nil {
+		logger.Errorf("service.RemoveCPCache: %v", err)
+	}
+	if err = s.cache.User.RemoveCPCache(s.ctx, cp.PartnerStuId); err != nil {
+		logger.Errorf("service.RemoveCPCache: %v", err)
+	}
+	return nil
+}
+
+// GetCP 返回 stuId 当前生效的 CP 伴侣以及在一起的天数、下一个月/年纪念日和等级。
+func (s *UserService) GetCP(stuId string) (*CPInfo, error) {
+	cp, err := s.db.User.GetActiveCPRelation(s.ctx, stuId)
+	if err != nil {
+		return nil, fmt.Errorf("service.GetActiveCPRelation: %w", err)
+	}
+	if cp == nil || cp.BoundAt == nil {
+		return nil, fmt.Errorf("service.GetCP: no active CP for %s", stuId)
+	}
+
+	boundAt := *cp.BoundAt
+	days := int(time.Since(boundAt).Hours() / 24)
+	return &CPInfo{
+		PartnerStuId:    cp.OtherSide(stuId),
+		BoundAt:         boundAt,
+		DaysTogether:    days,
+		NextMonthlyAnni: nextAnniversary(boundAt, 0, 1),
+		NextYearlyAnni:  nextAnniversary(boundAt, 1, 0),
+		Level:           cpLevel(days),
+	}, nil
+}
+
+// nextAnniversary 从 boundAt 开始按 years/months 步长往后推，直到推出一个还没到的日期。
+func nextAnniversary(boundAt time.Time, years, months int) time.Time {
+	next := boundAt
+	now := time.Now()
+	for !next.After(now) {
+		next = next.AddDate(years, months, 0)
+	}
+	return next
+}
+
+// cpLevel 按在一起的天数给出一个简单的等级称号。
+func cpLevel(days int) string {
+	switch {
+	case days < 30:
+		return "bronze"
+	case days < 180:
+		return "silver"
+	case days < 365:
+		return "gold"
+	default:
+		return "diamond"
+	}
+}
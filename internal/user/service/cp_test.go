@@ -0,0 +1,413 @@
+/*
+Copyright 2024 The west2-online Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bytedance/mockey"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+
+	"github.com/west2-online/fzuhelper-server/pkg/base"
+	"github.com/west2-online/fzuhelper-server/pkg/cache"
+	"github.com/west2-online/fzuhelper-server/pkg/cache/user"
+	"github.com/west2-online/fzuhelper-server/pkg/db"
+	dbmodel "github.com/west2-online/fzuhelper-server/pkg/db/model"
+	userDB "github.com/west2-online/fzuhelper-server/pkg/db/user"
+	"github.com/west2-online/fzuhelper-server/pkg/utils"
+)
+
+func newMockUserServiceForCP() *UserService {
+	mockClientSet := &base.ClientSet{
+		SFClient:    new(utils.Snowflake),
+		DBClient:    new(db.Database),
+		CacheClient: new(cache.Cache),
+	}
+	mockClientSet.CacheClient.User = &user.CacheUser{}
+	return NewUserService(context.Background(), "", nil, mockClientSet)
+}
+
+func TestUserService_InviteCP(t *testing.T) {
+	type testCase struct {
+		name string
+
+		expectingError    bool
+		expectingErrorMsg string
+
+		relationExist bool
+		relationError error
+		createError   error
+	}
+	stuId := "102300217"
+	friendId := "102300218"
+
+	testCases := []testCase{
+		{
+			name:              "relation check error",
+			expectingError:    true,
+			expectingErrorMsg: "service.GetRelationByUserId:",
+			relationError:     gorm.ErrInvalidData,
+		},
+		{
+			name:              "not friends",
+			expectingError:    true,
+			expectingErrorMsg: "service.InviteCP: not friends",
+			relationExist:     false,
+		},
+		{
+			name:              "active cp exists",
+			expectingError:    true,
+			expectingErrorMsg: "already has an active CP",
+			relationExist:     true,
+			createError:       userDB.ErrActiveCPExists,
+		},
+		{
+			name:              "db create error",
+			expectingError:    true,
+			expectingErrorMsg: "service.CreateCPInviteIfNoneActive:",
+			relationExist:     true,
+			createError:       gorm.ErrInvalidData,
+		},
+		{
+			name:           "success",
+			expectingError: false,
+			relationExist:  true,
+		},
+	}
+
+	defer mockey.UnPatchAll()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockey.PatchConvey(tc.name, t, func() {
+				userService := newMockUserServiceForCP()
+
+				mockey.Mock((*userDB.DBUser).GetRelationByUserId).To(func(ctx context.Context, stuId, friendId string) (bool, *dbmodel.FollowRelation, error) {
+					return tc.relationExist, nil, tc.relationError
+				}).Build()
+
+				mockey.Mock((*userDB.DBUser).CreateCPInviteIfNoneActive).To(func(ctx context.Context, stuId, friendId string) (*dbmodel.CPRelation, error) {
+					if tc.createError != nil {
+						return nil, tc.createError
+					}
+					return &dbmodel.CPRelation{StuId: stuId, PartnerStuId: friendId, State: dbmodel.CPStateInvited}, nil
+				}).Build()
+
+				err := userService.InviteCP(stuId, friendId)
+
+				if tc.expectingError {
+					assert.Error(t, err)
+					if tc.expectingErrorMsg != "" {
+						assert.Contains(t, err.Error(), tc.expectingErrorMsg)
+					}
+				} else {
+					assert.NoError(t, err)
+				}
+			})
+		})
+	}
+}
+
+func TestUserService_AcceptCP(t *testing.T) {
+	type testCase struct {
+		name string
+
+		expectingError    bool
+		expectingErrorMsg string
+
+		cp              *dbmodel.CPRelation
+		getActiveErr    error
+		acceptInviteErr error
+	}
+	stuId := "102300218"
+	friendId := "102300217"
+
+	testCases := []testCase{
+		{
+			name:              "get active cp error",
+			expectingError:    true,
+			expectingErrorMsg: "service.GetActiveCPRelation:",
+			getActiveErr:      gorm.ErrInvalidData,
+		},
+		{
+			name:              "no pending invitation",
+			expectingError:    true,
+			expectingErrorMsg: "service.AcceptCP: no pending invitation",
+			cp:                nil,
+		},
+		{
+			name:              "not the invitee",
+			expectingError:    true,
+			expectingErrorMsg: "service.AcceptCP: no pending invitation",
+			cp:                &dbmodel.CPRelation{ID: 1, StuId: friendId, PartnerStuId: stuId + "0", State: dbmodel.CPStateInvited},
+		},
+		{
+			name:              "accept invite error",
+			expectingError:    true,
+			expectingErrorMsg: "service.AcceptCPInvite:",
+			cp:                &dbmodel.CPRelation{ID: 1, StuId: friendId, PartnerStuId: stuId, State: dbmodel.CPStateInvited},
+			acceptInviteErr:   gorm.ErrInvalidData,
+		},
+		{
+			name:           "success",
+			expectingError: false,
+			cp:             &dbmodel.CPRelation{ID: 1, StuId: friendId, PartnerStuId: stuId, State: dbmodel.CPStateInvited},
+		},
+	}
+
+	defer mockey.UnPatchAll()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockey.PatchConvey(tc.name, t, func() {
+				userService := newMockUserServiceForCP()
+
+				mockey.Mock((*userDB.DBUser).GetActiveCPRelation).To(func(ctx context.Context, id string) (*dbmodel.CPRelation, error) {
+					return tc.cp, tc.getActiveErr
+				}).Build()
+				mockey.Mock((*userDB.DBUser).AcceptCPInvite).To(func(ctx context.Context, id int64) error {
+					return tc.acceptInviteErr
+				}).Build()
+				mockey.Mock((*user.CacheUser).SetCPCache).To(func(ctx context.Context, stuId, partnerStuId string) error {
+					return nil
+				}).Build()
+
+				err := userService.AcceptCP(stuId)
+
+				if tc.expectingError {
+					assert.Error(t, err)
+					if tc.expectingErrorMsg != "" {
+						assert.Contains(t, err.Error(), tc.expectingErrorMsg)
+					}
+				} else {
+					assert.NoError(t, err)
+				}
+			})
+		})
+	}
+}
+
+func TestUserService_CancelCP(t *testing.T) {
+	type testCase struct {
+		name string
+
+		expectingError    bool
+		expectingErrorMsg string
+
+		cp              *dbmodel.CPRelation
+		getActiveErr    error
+		cancelInviteErr error
+	}
+	stuId := "102300217"
+
+	testCases := []testCase{
+		{
+			name:              "get active cp error",
+			expectingError:    true,
+			expectingErrorMsg: "service.GetActiveCPRelation:",
+			getActiveErr:      gorm.ErrInvalidData,
+		},
+		{
+			name:              "no active cp",
+			expectingError:    true,
+			expectingErrorMsg: "service.CancelCP: no active CP",
+			cp:                nil,
+		},
+		{
+			name:              "cancel invite error",
+			expectingError:    true,
+			expectingErrorMsg: "service.CancelCPInvite:",
+			cp:                &dbmodel.CPRelation{ID: 1, StuId: stuId, State: dbmodel.CPStateInvited},
+			cancelInviteErr:   gorm.ErrInvalidData,
+		},
+		{
+			name:           "success",
+			expectingError: false,
+			cp:             &dbmodel.CPRelation{ID: 1, StuId: stuId, State: dbmodel.CPStateInvited},
+		},
+	}
+
+	defer mockey.UnPatchAll()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockey.PatchConvey(tc.name, t, func() {
+				userService := newMockUserServiceForCP()
+
+				mockey.Mock((*userDB.DBUser).GetActiveCPRelation).To(func(ctx context.Context, id string) (*dbmodel.CPRelation, error) {
+					return tc.cp, tc.getActiveErr
+				}).Build()
+				mockey.Mock((*userDB.DBUser).CancelCPInvite).To(func(ctx context.Context, id int64) error {
+					return tc.cancelInviteErr
+				}).Build()
+
+				err := userService.CancelCP(stuId)
+
+				if tc.expectingError {
+					assert.Error(t, err)
+					if tc.expectingErrorMsg != "" {
+						assert.Contains(t, err.Error(), tc.expectingErrorMsg)
+					}
+				} else {
+					assert.NoError(t, err)
+				}
+			})
+		})
+	}
+}
+
+func TestUserService_BreakCP(t *testing.T) {
+	type testCase struct {
+		name string
+
+		expectingError    bool
+		expectingErrorMsg string
+
+		cp           *dbmodel.CPRelation
+		getActiveErr error
+		breakErr     error
+	}
+	stuId := "102300217"
+	partnerStuId := "102300218"
+
+	testCases := []testCase{
+		{
+			name:              "get active cp error",
+			expectingError:    true,
+			expectingErrorMsg: "service.GetActiveCPRelation:",
+			getActiveErr:      gorm.ErrInvalidData,
+		},
+		{
+			name:              "no active cp",
+			expectingError:    true,
+			expectingErrorMsg: "service.BreakCP: no active CP",
+			cp:                nil,
+		},
+		{
+			name:              "break relation error",
+			expectingError:    true,
+			expectingErrorMsg: "service.BreakCPRelation:",
+			cp:                &dbmodel.CPRelation{ID: 1, StuId: stuId, PartnerStuId: partnerStuId, State: dbmodel.CPStateAccepted},
+			breakErr:          gorm.ErrInvalidData,
+		},
+		{
+			name:           "success",
+			expectingError: false,
+			cp:             &dbmodel.CPRelation{ID: 1, StuId: stuId, PartnerStuId: partnerStuId, State: dbmodel.CPStateAccepted},
+		},
+	}
+
+	defer mockey.UnPatchAll()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockey.PatchConvey(tc.name, t, func() {
+				userService := newMockUserServiceForCP()
+
+				mockey.Mock((*userDB.DBUser).GetActiveCPRelation).To(func(ctx context.Context, id string) (*dbmodel.CPRelation, error) {
+					return tc.cp, tc.getActiveErr
+				}).Build()
+				mockey.Mock((*userDB.DBUser).BreakCPRelation).To(func(ctx context.Context, id int64) error {
+					return tc.breakErr
+				}).Build()
+				mockey.Mock((*user.CacheUser).RemoveCPCache).To(func(ctx context.Context, stuId string) error {
+					return nil
+				}).Build()
+
+				err := userService.BreakCP(stuId)
+
+				if tc.expectingError {
+					assert.Error(t, err)
+					if tc.expectingErrorMsg != "" {
+						assert.Contains(t, err.Error(), tc.expectingErrorMsg)
+					}
+				} else {
+					assert.NoError(t, err)
+				}
+			})
+		})
+	}
+}
+
+func TestUserService_GetCP(t *testing.T) {
+	type testCase struct {
+		name string
+
+		expectingError    bool
+		expectingErrorMsg string
+
+		cp           *dbmodel.CPRelation
+		getActiveErr error
+
+		expectedLevel string
+	}
+	stuId := "102300217"
+	partnerStuId := "102300218"
+	boundAt := time.Now().Add(-48 * time.Hour)
+
+	testCases := []testCase{
+		{
+			name:              "get active cp error",
+			expectingError:    true,
+			expectingErrorMsg: "service.GetActiveCPRelation:",
+			getActiveErr:      gorm.ErrInvalidData,
+		},
+		{
+			name:              "no active cp",
+			expectingError:    true,
+			expectingErrorMsg: "service.GetCP: no active CP",
+			cp:                nil,
+		},
+		{
+			name:              "not bound yet",
+			expectingError:    true,
+			expectingErrorMsg: "service.GetCP: no active CP",
+			cp:                &dbmodel.CPRelation{ID: 1, StuId: stuId, PartnerStuId: partnerStuId, State: dbmodel.CPStateInvited},
+		},
+		{
+			name:           "success",
+			expectingError: false,
+			cp:             &dbmodel.CPRelation{ID: 1, StuId: stuId, PartnerStuId: partnerStuId, State: dbmodel.CPStateAccepted, BoundAt: &boundAt},
+			expectedLevel:  "bronze",
+		},
+	}
+
+	defer mockey.UnPatchAll()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockey.PatchConvey(tc.name, t, func() {
+				userService := newMockUserServiceForCP()
+
+				mockey.Mock((*userDB.DBUser).GetActiveCPRelation).To(func(ctx context.Context, id string) (*dbmodel.CPRelation, error) {
+					return tc.cp, tc.getActiveErr
+				}).Build()
+
+				info, err := userService.GetCP(stuId)
+
+				if tc.expectingError {
+					assert.Error(t, err)
+					if tc.expectingErrorMsg != "" {
+						assert.Contains(t, err.Error(), tc.expectingErrorMsg)
+					}
+				} else {
+					assert.NoError(t, err)
+					assert.Equal(t, partnerStuId, info.PartnerStuId)
+					assert.Equal(t, tc.expectedLevel, info.Level)
+				}
+			})
+		})
+	}
+}
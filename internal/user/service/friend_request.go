@@ -0,0 +1,89 @@
+/*
+Copyright 2024 The west2-online Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	dbmodel "github.com/west2-online/fzuhelper-server/pkg/db/model"
+	userDB "github.com/west2-online/fzuhelper-server/pkg/db/user"
+)
+
+// SendFriendRequest 向目标学号发起一条待处理的好友申请，只有对方 Accept 之后才会建立关系。
+func (s *UserService) SendFriendRequest(fromStuId, toStuId, message string) (*dbmodel.FriendRequest, error) {
+	if fromStuId == toStuId {
+		return nil, fmt.Errorf("service.SendFriendRequest: cannot add yourself as friend")
+	}
+	ok, _, err := s.db.User.GetRelationByUserId(s.ctx, fromStuId, toStuId)
+	if err != nil {
+		return nil, fmt.Errorf("service.GetRelationByUserId: %w", err)
+	}
+	if ok {
+		return nil, fmt.Errorf("service.SendFriendRequest: RelationShip Already Exist")
+	}
+
+	req, err := s.db.User.CreateFriendRequest(s.ctx, fromStuId, toStuId, message)
+	if err != nil {
+		return nil, fmt.Errorf("service.CreateFriendRequest: %w", err)
+	}
+	return req, nil
+}
+
+// AcceptFriendRequest 由申请接收方调用，落地双向关系；两侧的好友缓存由 outbox dispatcher 异步回填。
+// 屏蔽检查和关系落地在同一个事务里完成，避免 Accept 期间刚好被对方屏蔽的竞态。
+func (s *UserService) AcceptFriendRequest(requestId int64, acceptorStuId string) error {
+	req, err := s.db.User.GetFriendRequestById(s.ctx, requestId)
+	if err != nil {
+		return fmt.Errorf("service.GetFriendRequestById: %w", err)
+	}
+	if req.ToStuId != acceptorStuId {
+		return fmt.Errorf("service.AcceptFriendRequest: not the recipient of this request")
+	}
+
+	if err = s.db.User.AcceptFriendRequestWithOutbox(s.ctx, requestId, req.FromStuId, req.ToStuId); err != nil {
+		if errors.Is(err, userDB.ErrBlocked) {
+			return fmt.Errorf("service.AcceptFriendRequest: blocked")
+		}
+		return fmt.Errorf("service.AcceptFriendRequestWithOutbox: %w", err)
+	}
+	return nil
+}
+
+// RejectFriendRequest 只把申请标记为 rejected，不会留下任何关系。
+func (s *UserService) RejectFriendRequest(requestId int64, rejecterStuId string) error {
+	req, err := s.db.User.GetFriendRequestById(s.ctx, requestId)
+	if err != nil {
+		return fmt.Errorf("service.GetFriendRequestById: %w", err)
+	}
+	if req.ToStuId != rejecterStuId {
+		return fmt.Errorf("service.RejectFriendRequest: not the recipient of this request")
+	}
+	if err = s.db.User.UpdateFriendRequestState(s.ctx, requestId, dbmodel.FriendRequestStateRejected); err != nil {
+		return fmt.Errorf("service.UpdateFriendRequestState: %w", err)
+	}
+	return nil
+}
+
+// ListPendingRequests 列出发给当前学号的所有待处理好友申请。
+func (s *UserService) ListPendingRequests(stuId string) ([]*dbmodel.FriendRequest, error) {
+	reqs, err := s.db.User.ListPendingRequests(s.ctx, stuId)
+	if err != nil {
+		return nil, fmt.Errorf("service.ListPendingRequests: %w", err)
+	}
+	return reqs, nil
+}
@@ -0,0 +1,325 @@
+/*
+Copyright 2024 The west2-online Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bytedance/mockey"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+
+	"github.com/west2-online/fzuhelper-server/pkg/base"
+	"github.com/west2-online/fzuhelper-server/pkg/cache"
+	"github.com/west2-online/fzuhelper-server/pkg/cache/user"
+	"github.com/west2-online/fzuhelper-server/pkg/db"
+	dbmodel "github.com/west2-online/fzuhelper-server/pkg/db/model"
+	userDB "github.com/west2-online/fzuhelper-server/pkg/db/user"
+	"github.com/west2-online/fzuhelper-server/pkg/utils"
+)
+
+func newMockUserServiceForFriendRequest() *UserService {
+	mockClientSet := &base.ClientSet{
+		SFClient:    new(utils.Snowflake),
+		DBClient:    new(db.Database),
+		CacheClient: new(cache.Cache),
+	}
+	mockClientSet.CacheClient.User = &user.CacheUser{}
+	return NewUserService(context.Background(), "", nil, mockClientSet)
+}
+
+func TestUserService_SendFriendRequest(t *testing.T) {
+	type testCase struct {
+		name string
+
+		expectingError    bool
+		expectingErrorMsg string
+
+		relationExist bool
+		relationError error
+		createError   error
+	}
+	fromStuId := "102300217"
+	toStuId := "102300218"
+
+	testCases := []testCase{
+		{
+			name:              "add self as friend",
+			expectingError:    true,
+			expectingErrorMsg: "service.SendFriendRequest: cannot add yourself as friend",
+		},
+		{
+			name:              "relation check error",
+			expectingError:    true,
+			expectingErrorMsg: "service.GetRelationByUserId:",
+			relationError:     gorm.ErrInvalidData,
+		},
+		{
+			name:              "relation already exist",
+			expectingError:    true,
+			expectingErrorMsg: "service.SendFriendRequest: RelationShip Already Exist",
+			relationExist:     true,
+		},
+		{
+			name:              "db create error",
+			expectingError:    true,
+			expectingErrorMsg: "service.CreateFriendRequest:",
+			createError:       gorm.ErrInvalidData,
+		},
+		{
+			name:           "success",
+			expectingError: false,
+		},
+	}
+
+	defer mockey.UnPatchAll()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockey.PatchConvey(tc.name, t, func() {
+				userService := newMockUserServiceForFriendRequest()
+				stuId, friendId := fromStuId, toStuId
+				if tc.name == "add self as friend" {
+					friendId = fromStuId
+				}
+
+				mockey.Mock((*userDB.DBUser).GetRelationByUserId).To(func(ctx context.Context, a, b string) (bool, *dbmodel.FollowRelation, error) {
+					return tc.relationExist, nil, tc.relationError
+				}).Build()
+				mockey.Mock((*userDB.DBUser).CreateFriendRequest).To(func(ctx context.Context, fromStuId, toStuId, message string) (*dbmodel.FriendRequest, error) {
+					if tc.createError != nil {
+						return nil, tc.createError
+					}
+					return &dbmodel.FriendRequest{FromStuId: fromStuId, ToStuId: toStuId, State: dbmodel.FriendRequestStatePending}, nil
+				}).Build()
+
+				req, err := userService.SendFriendRequest(stuId, friendId, "")
+
+				if tc.expectingError {
+					assert.Error(t, err)
+					if tc.expectingErrorMsg != "" {
+						assert.Contains(t, err.Error(), tc.expectingErrorMsg)
+					}
+				} else {
+					assert.NoError(t, err)
+					assert.Equal(t, dbmodel.FriendRequestStatePending, req.State)
+				}
+			})
+		})
+	}
+}
+
+func TestUserService_AcceptFriendRequest(t *testing.T) {
+	type testCase struct {
+		name string
+
+		expectingError    bool
+		expectingErrorMsg string
+
+		req       *dbmodel.FriendRequest
+		getReqErr error
+		acceptErr error
+	}
+	acceptorStuId := "102300218"
+
+	testCases := []testCase{
+		{
+			name:              "get request error",
+			expectingError:    true,
+			expectingErrorMsg: "service.GetFriendRequestById:",
+			getReqErr:         gorm.ErrInvalidData,
+		},
+		{
+			name:              "not the recipient",
+			expectingError:    true,
+			expectingErrorMsg: "service.AcceptFriendRequest: not the recipient of this request",
+			req:               &dbmodel.FriendRequest{ID: 1, FromStuId: "102300217", ToStuId: "102300219"},
+		},
+		{
+			name:              "blocked",
+			expectingError:    true,
+			expectingErrorMsg: "service.AcceptFriendRequest: blocked",
+			req:               &dbmodel.FriendRequest{ID: 1, FromStuId: "102300217", ToStuId: acceptorStuId},
+			acceptErr:         userDB.ErrBlocked,
+		},
+		{
+			name:              "accept transaction error",
+			expectingError:    true,
+			expectingErrorMsg: "service.AcceptFriendRequestWithOutbox:",
+			req:               &dbmodel.FriendRequest{ID: 1, FromStuId: "102300217", ToStuId: acceptorStuId},
+			acceptErr:         gorm.ErrInvalidData,
+		},
+		{
+			name:           "success",
+			expectingError: false,
+			req:            &dbmodel.FriendRequest{ID: 1, FromStuId: "102300217", ToStuId: acceptorStuId},
+		},
+	}
+
+	defer mockey.UnPatchAll()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockey.PatchConvey(tc.name, t, func() {
+				userService := newMockUserServiceForFriendRequest()
+
+				mockey.Mock((*userDB.DBUser).GetFriendRequestById).To(func(ctx context.Context, requestId int64) (*dbmodel.FriendRequest, error) {
+					if tc.getReqErr != nil {
+						return nil, tc.getReqErr
+					}
+					return tc.req, nil
+				}).Build()
+				mockey.Mock((*userDB.DBUser).AcceptFriendRequestWithOutbox).To(func(ctx context.Context, requestId int64, fromStuId, toStuId string) error {
+					return tc.acceptErr
+				}).Build()
+
+				err := userService.AcceptFriendRequest(1, acceptorStuId)
+
+				if tc.expectingError {
+					assert.Error(t, err)
+					if tc.expectingErrorMsg != "" {
+						assert.Contains(t, err.Error(), tc.expectingErrorMsg)
+					}
+				} else {
+					assert.NoError(t, err)
+				}
+			})
+		})
+	}
+}
+
+func TestUserService_RejectFriendRequest(t *testing.T) {
+	type testCase struct {
+		name string
+
+		expectingError    bool
+		expectingErrorMsg string
+
+		req            *dbmodel.FriendRequest
+		getReqErr      error
+		updateStateErr error
+	}
+	rejecterStuId := "102300218"
+
+	testCases := []testCase{
+		{
+			name:              "get request error",
+			expectingError:    true,
+			expectingErrorMsg: "service.GetFriendRequestById:",
+			getReqErr:         gorm.ErrInvalidData,
+		},
+		{
+			name:              "not the recipient",
+			expectingError:    true,
+			expectingErrorMsg: "service.RejectFriendRequest: not the recipient of this request",
+			req:               &dbmodel.FriendRequest{ID: 1, FromStuId: "102300217", ToStuId: "102300219"},
+		},
+		{
+			name:              "update state error",
+			expectingError:    true,
+			expectingErrorMsg: "service.UpdateFriendRequestState:",
+			req:               &dbmodel.FriendRequest{ID: 1, FromStuId: "102300217", ToStuId: rejecterStuId},
+			updateStateErr:    gorm.ErrInvalidData,
+		},
+		{
+			name:           "success",
+			expectingError: false,
+			req:            &dbmodel.FriendRequest{ID: 1, FromStuId: "102300217", ToStuId: rejecterStuId},
+		},
+	}
+
+	defer mockey.UnPatchAll()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockey.PatchConvey(tc.name, t, func() {
+				userService := newMockUserServiceForFriendRequest()
+
+				mockey.Mock((*userDB.DBUser).GetFriendRequestById).To(func(ctx context.Context, requestId int64) (*dbmodel.FriendRequest, error) {
+					if tc.getReqErr != nil {
+						return nil, tc.getReqErr
+					}
+					return tc.req, nil
+				}).Build()
+				mockey.Mock((*userDB.DBUser).UpdateFriendRequestState).To(func(ctx context.Context, requestId int64, state string) error {
+					return tc.updateStateErr
+				}).Build()
+
+				err := userService.RejectFriendRequest(1, rejecterStuId)
+
+				if tc.expectingError {
+					assert.Error(t, err)
+					if tc.expectingErrorMsg != "" {
+						assert.Contains(t, err.Error(), tc.expectingErrorMsg)
+					}
+				} else {
+					assert.NoError(t, err)
+				}
+			})
+		})
+	}
+}
+
+func TestUserService_ListPendingRequests(t *testing.T) {
+	type testCase struct {
+		name string
+
+		expectingError    bool
+		expectingErrorMsg string
+
+		dbReqs  []*dbmodel.FriendRequest
+		dbError error
+	}
+	stuId := "102300217"
+
+	testCases := []testCase{
+		{
+			name:              "db error",
+			expectingError:    true,
+			expectingErrorMsg: "service.ListPendingRequests:",
+			dbError:           gorm.ErrInvalidData,
+		},
+		{
+			name:           "success",
+			expectingError: false,
+			dbReqs:         []*dbmodel.FriendRequest{{ID: 1, FromStuId: "102300218", ToStuId: stuId}},
+		},
+	}
+
+	defer mockey.UnPatchAll()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockey.PatchConvey(tc.name, t, func() {
+				userService := newMockUserServiceForFriendRequest()
+
+				mockey.Mock((*userDB.DBUser).ListPendingRequests).To(func(ctx context.Context, stuId string) ([]*dbmodel.FriendRequest, error) {
+					return tc.dbReqs, tc.dbError
+				}).Build()
+
+				reqs, err := userService.ListPendingRequests(stuId)
+
+				if tc.expectingError {
+					assert.Error(t, err)
+					if tc.expectingErrorMsg != "" {
+						assert.Contains(t, err.Error(), tc.expectingErrorMsg)
+					}
+				} else {
+					assert.NoError(t, err)
+					assert.Equal(t, tc.dbReqs, reqs)
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,46 @@
+/*
+Copyright 2024 The west2-online Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/west2-online/fzuhelper-server/pkg/utils"
+)
+
+// GenerateInvitation 创建一张邀请码元数据记录，并把其自增 ID 编码为对外可见的 HashID。
+// ttl 为 0 或 maxUses 为 0 都没有特殊含义，分别代表立即过期、不可使用，由调用方自行校验。
+// autoAccept 为 true 时，持有该邀请码绑定好友会跳过待处理队列直接建立关系。
+func (s *UserService) GenerateInvitation(stuId string, ttl time.Duration, maxUses int64, autoAccept bool) (string, error) {
+	invitation, err := s.db.User.CreateInvitationCode(s.ctx, stuId, ttl, maxUses, autoAccept)
+	if err != nil {
+		return "", fmt.Errorf("service.CreateInvitationCode: %w", err)
+	}
+
+	code, err := utils.EncodeInvitationId(invitation.ID)
+	if err != nil {
+		return "", fmt.Errorf("service.GenerateInvitation: %w", err)
+	}
+
+	mapKey := fmt.Sprintf("code_mapping:%s", code)
+	if err = s.cache.User.SetCodeStuIdMappingCache(s.ctx, mapKey, stuId); err != nil {
+		return "", fmt.Errorf("service.SetCodeStuIdMappingCache: %w", err)
+	}
+
+	return code, nil
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2024 The west2-online Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package user
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const userBlockCacheExpire = 24 * time.Hour
+
+// userBlockCacheEmptyMarker 是写进屏蔽名单集合的占位成员，让「stuId 谁都没屏蔽」也能被缓存下来，
+// 否则一个空集合在 Redis 里等于不存在，每次 IsBlocked 都会判定成未加载从而穿透回源 DB。
+const userBlockCacheEmptyMarker = "\x00empty"
+
+func userBlockCacheKey(stuId string) string {
+	return fmt.Sprintf("user_block:%s", stuId)
+}
+
+// SetUserBlockCache 把 blockedStuId 加入 stuId 的屏蔽名单缓存，每次写入都会刷新过期时间。
+func (cu *CacheUser) SetUserBlockCache(ctx context.Context, stuId, blockedStuId string) error {
+	key := userBlockCacheKey(stuId)
+	if err := cu.client.SAdd(ctx, key, blockedStuId).Err(); err != nil {
+		return err
+	}
+	return cu.client.Expire(ctx, key, userBlockCacheExpire).Err()
+}
+
+// MarkUserBlockCacheEmpty 在 stuId 没有屏蔽任何人时，仍然把「已加载」状态缓存下来，避免每次都回源 DB。
+func (cu *CacheUser) MarkUserBlockCacheEmpty(ctx context.Context, stuId string) error {
+	key := userBlockCacheKey(stuId)
+	if err := cu.client.SAdd(ctx, key, userBlockCacheEmptyMarker).Err(); err != nil {
+		return err
+	}
+	return cu.client.Expire(ctx, key, userBlockCacheExpire).Err()
+}
+
+// InvalidateUserBlockCache 整体删除 stuId 的屏蔽名单缓存，下一次 IsBlocked 会重新从 DB 懒加载全量
+// 数据；新增一条屏蔽记录时必须用它而不是直接 SAdd，否则缓存过期后首次写入只会带上新屏蔽的那一个人，
+// 却被 IsUserBlockCacheExist 判定成「已加载」，导致之前屏蔽过的人被漏判。
+func (cu *CacheUser) InvalidateUserBlockCache(ctx context.Context, stuId string) error {
+	return cu.client.Del(ctx, userBlockCacheKey(stuId)).Err()
+}
+
+// RemoveUserBlockCache 把 blockedStuId 从 stuId 的屏蔽名单缓存中移除。
+func (cu *CacheUser) RemoveUserBlockCache(ctx context.Context, stuId, blockedStuId string) error {
+	return cu.client.SRem(ctx, userBlockCacheKey(stuId), blockedStuId).Err()
+}
+
+// IsUserBlockCacheExist 判断 stuId 的屏蔽名单是否已经加载到缓存中，用于懒加载判断。
+func (cu *CacheUser) IsUserBlockCacheExist(ctx context.Context, stuId string) (bool, error) {
+	n, err := cu.client.Exists(ctx, userBlockCacheKey(stuId)).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// GetUserBlockCache 返回 stuId 缓存中的屏蔽名单，调用前应先确认缓存已经被加载。
+func (cu *CacheUser) GetUserBlockCache(ctx context.Context, stuId string) ([]string, error) {
+	members, err := cu.client.SMembers(ctx, userBlockCacheKey(stuId)).Result()
+	if err != nil {
+		return nil, err
+	}
+	blocked := make([]string, 0, len(members))
+	for _, m := range members {
+		if m == userBlockCacheEmptyMarker {
+			continue
+		}
+		blocked = append(blocked, m)
+	}
+	return blocked, nil
+}
+
+// IsBlockedInCache 判断 blockedStuId 是否在 stuId 的屏蔽名单缓存中。
+func (cu *CacheUser) IsBlockedInCache(ctx context.Context, stuId, blockedStuId string) (bool, error) {
+	return cu.client.SIsMember(ctx, userBlockCacheKey(stuId), blockedStuId).Result()
+}
@@ -0,0 +1,39 @@
+/*
+Copyright 2024 The west2-online Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package user
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const cpCacheExpire = 24 * time.Hour
+
+func cpCacheKey(stuId string) string {
+	return fmt.Sprintf("cp:%s", stuId)
+}
+
+// SetCPCache 缓存 stuId 当前的 CP 伴侣学号。
+func (cu *CacheUser) SetCPCache(ctx context.Context, stuId, partnerStuId string) error {
+	return cu.client.Set(ctx, cpCacheKey(stuId), partnerStuId, cpCacheExpire).Err()
+}
+
+// RemoveCPCache 在 CP 关系被解除时清掉缓存；还处于 invited 状态的邀请从未写过缓存，取消时无需调用。
+func (cu *CacheUser) RemoveCPCache(ctx context.Context, stuId string) error {
+	return cu.client.Del(ctx, cpCacheKey(stuId)).Err()
+}
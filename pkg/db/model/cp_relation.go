@@ -0,0 +1,50 @@
+/*
+Copyright 2024 The west2-online Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package model
+
+import "time"
+
+const (
+	CPStateInvited   = "invited"
+	CPStateAccepted  = "accepted"
+	CPStateCancelled = "cancelled"
+	CPStateBroken    = "broken"
+)
+
+// CPRelation 是叠加在好友关系上的排他情侣关系：同一时间每个人最多只有一段 invited/accepted 状态
+// 的 CP，BoundAt 在 Accept 时写入，用来计算纪念日。和好友关系不同，CP 状态变化不会动好友表。
+type CPRelation struct {
+	ID           int64      `gorm:"primarykey;autoIncrement" json:"id"`
+	StuId        string     `gorm:"column:stu_id;type:varchar(20);index;not null" json:"stu_id"`
+	PartnerStuId string     `gorm:"column:partner_stu_id;type:varchar(20);index;not null" json:"partner_stu_id"`
+	State        string     `gorm:"column:state;type:varchar(20);not null" json:"state"`
+	BoundAt      *time.Time `gorm:"column:bound_at" json:"bound_at"`
+	CreatedAt    time.Time  `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt    time.Time  `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+}
+
+func (CPRelation) TableName() string {
+	return "cp_relation"
+}
+
+// OtherSide 返回关系中除 stuId 以外的另一方学号。
+func (c *CPRelation) OtherSide(stuId string) string {
+	if c.StuId == stuId {
+		return c.PartnerStuId
+	}
+	return c.StuId
+}
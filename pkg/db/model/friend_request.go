@@ -0,0 +1,41 @@
+/*
+Copyright 2024 The west2-online Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package model
+
+import "time"
+
+const (
+	FriendRequestStatePending  = "pending"
+	FriendRequestStateAccepted = "accepted"
+	FriendRequestStateRejected = "rejected"
+)
+
+// FriendRequest 记录一次好友申请，只有 Accept 之后才会在 FollowRelation 中落地双向关系，
+// Reject 只是把状态置为 rejected，不留下任何关系记录。
+type FriendRequest struct {
+	ID        int64     `gorm:"primarykey;autoIncrement" json:"id"`
+	FromStuId string    `gorm:"column:from_stu_id;type:varchar(20);index;not null" json:"from_stu_id"`
+	ToStuId   string    `gorm:"column:to_stu_id;type:varchar(20);index;not null" json:"to_stu_id"`
+	Message   string    `gorm:"column:message;type:varchar(255)" json:"message"`
+	State     string    `gorm:"column:state;type:varchar(20);not null;default:pending" json:"state"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+}
+
+func (FriendRequest) TableName() string {
+	return "friend_request"
+}
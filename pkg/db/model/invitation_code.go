@@ -0,0 +1,47 @@
+/*
+Copyright 2024 The west2-online Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package model
+
+import "time"
+
+// InvitationCode 记录邀请码的元数据，邀请码本身只是一个对 ID 做 HashID 编码后的不透明字符串，
+// owner / 有效期 / 剩余可用次数都以这张表为准，缓存里的 code_mapping 只是旁路，不参与校验。
+type InvitationCode struct {
+	ID            int64     `gorm:"primarykey;autoIncrement" json:"id"`
+	OwnerStuId    string    `gorm:"column:owner_stu_id;type:varchar(20);index;not null" json:"owner_stu_id"`
+	RemainingUses int64     `gorm:"column:remaining_uses;not null" json:"remaining_uses"`
+	ExpiresAt     time.Time `gorm:"column:expires_at;not null" json:"expires_at"`
+	// AutoAccept 为 true 时，持有该邀请码绑定好友会跳过待处理队列直接建立关系，
+	// 由邀请码创建者在生成时显式开启，默认情况下一律走 FriendRequest 待处理流程。
+	AutoAccept bool      `gorm:"column:auto_accept;not null" json:"auto_accept"`
+	CreatedAt  time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+func (InvitationCode) TableName() string {
+	return "invitation_code"
+}
+
+// IsAvailable 判断邀请码是否还可以被使用：次数耗尽或者已经过期都视为不可用。
+func (i *InvitationCode) IsAvailable() bool {
+	if i.RemainingUses == 0 {
+		return false
+	}
+	if time.Now().After(i.ExpiresAt) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,35 @@
+/*
+Copyright 2024 The west2-online Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package model
+
+import "time"
+
+// RelationEvent 是好友关系建立的事务性 outbox 记录：与 FollowRelation 写在同一个事务里，
+// 保证即使进程在写完关系后立刻死掉，好友缓存的双向回填和邀请码映射的清理也不会丢失。
+type RelationEvent struct {
+	ID             int64      `gorm:"primarykey;autoIncrement" json:"id"`
+	StuId          string     `gorm:"column:stu_id;type:varchar(20);not null" json:"stu_id"`
+	FriendId       string     `gorm:"column:friend_id;type:varchar(20);not null" json:"friend_id"`
+	CodeMappingKey string     `gorm:"column:code_mapping_key;type:varchar(64)" json:"code_mapping_key"`
+	Processed      bool       `gorm:"column:processed;not null;index" json:"processed"`
+	CreatedAt      time.Time  `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	ProcessedAt    *time.Time `gorm:"column:processed_at" json:"processed_at"`
+}
+
+func (RelationEvent) TableName() string {
+	return "relation_events"
+}
@@ -0,0 +1,31 @@
+/*
+Copyright 2024 The west2-online Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package model
+
+import "time"
+
+// UserBlock 记录一次单向屏蔽：stu_id 屏蔽了 blocked_stu_id，屏蔽关系不要求对方同意或感知。
+type UserBlock struct {
+	ID           int64     `gorm:"primarykey;autoIncrement" json:"id"`
+	StuId        string    `gorm:"column:stu_id;type:varchar(20);index;not null" json:"stu_id"`
+	BlockedStuId string    `gorm:"column:blocked_stu_id;type:varchar(20);index;not null" json:"blocked_stu_id"`
+	CreatedAt    time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+func (UserBlock) TableName() string {
+	return "user_block"
+}
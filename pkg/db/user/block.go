@@ -0,0 +1,65 @@
+/*
+Copyright 2024 The west2-online Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package user
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	dbmodel "github.com/west2-online/fzuhelper-server/pkg/db/model"
+)
+
+// BlockUser 建立一条屏蔽记录，并在同一事务内清掉双方已有的好友关系和待处理的好友申请；
+// 已经 accepted/rejected 的历史好友申请记录不受影响。
+func (dbu *DBUser) BlockUser(ctx context.Context, stuId, blockedStuId string) error {
+	return dbu.client.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&dbmodel.UserBlock{StuId: stuId, BlockedStuId: blockedStuId}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("(stu_id = ? AND friend_id = ?) OR (stu_id = ? AND friend_id = ?)",
+			stuId, blockedStuId, blockedStuId, stuId).Delete(&dbmodel.FollowRelation{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("((from_stu_id = ? AND to_stu_id = ?) OR (from_stu_id = ? AND to_stu_id = ?)) AND state = ?",
+			stuId, blockedStuId, blockedStuId, stuId, dbmodel.FriendRequestStatePending).
+			Delete(&dbmodel.FriendRequest{}).Error; err != nil {
+			return err
+		}
+		return nil
+	})
+}
+
+// UnblockUser 撤销一条屏蔽记录，不会恢复被清掉的好友关系或好友申请。
+func (dbu *DBUser) UnblockUser(ctx context.Context, stuId, blockedStuId string) error {
+	return dbu.client.WithContext(ctx).
+		Where("stu_id = ? AND blocked_stu_id = ?", stuId, blockedStuId).
+		Delete(&dbmodel.UserBlock{}).Error
+}
+
+// ListBlocked 返回 stuId 屏蔽的所有学号列表。
+func (dbu *DBUser) ListBlocked(ctx context.Context, stuId string) ([]string, error) {
+	var blocks []dbmodel.UserBlock
+	if err := dbu.client.WithContext(ctx).Where("stu_id = ?", stuId).Find(&blocks).Error; err != nil {
+		return nil, err
+	}
+	blocked := make([]string, 0, len(blocks))
+	for _, b := range blocks {
+		blocked = append(blocked, b.BlockedStuId)
+	}
+	return blocked, nil
+}
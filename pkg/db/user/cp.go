@@ -0,0 +1,122 @@
+/*
+Copyright 2024 The west2-online Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package user
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	dbmodel "github.com/west2-online/fzuhelper-server/pkg/db/model"
+)
+
+// ErrActiveCPExists 表示 stuId、friendId 中有一方已经存在 invited/accepted 状态的 CP。
+var ErrActiveCPExists = errors.New("active cp exists")
+
+// CreateCPInviteIfNoneActive 在同一个事务里重新确认 stuId、friendId 都没有处于 invited/accepted
+// 状态的 CP，然后再插入新的邀请，把“至多一个生效中的 CP”的检查和落地并入同一个事务。确认查询带
+// FOR UPDATE 行锁/间隙锁，否则普通的 SELECT COUNT(*) 在 REPEATABLE READ 下不会阻塞，两次并发邀请
+// 仍然可能都读到 count == 0 然后都插入成功。
+func (dbu *DBUser) CreateCPInviteIfNoneActive(ctx context.Context, stuId, friendId string) (cp *dbmodel.CPRelation, err error) {
+	err = dbu.client.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, id := range []string{stuId, friendId} {
+			var count int64
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Model(&dbmodel.CPRelation{}).
+				Where("(stu_id = ? OR partner_stu_id = ?) AND state IN ?", id, id,
+					[]string{dbmodel.CPStateInvited, dbmodel.CPStateAccepted}).
+				Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return ErrActiveCPExists
+			}
+		}
+		c := &dbmodel.CPRelation{
+			StuId:        stuId,
+			PartnerStuId: friendId,
+			State:        dbmodel.CPStateInvited,
+		}
+		if err := tx.Create(c).Error; err != nil {
+			return err
+		}
+		cp = c
+		return nil
+	})
+	return cp, err
+}
+
+// GetActiveCPRelation 返回 stuId 当前处于 invited 或 accepted 状态的 CP，不存在时返回 nil。
+func (dbu *DBUser) GetActiveCPRelation(ctx context.Context, stuId string) (*dbmodel.CPRelation, error) {
+	var cp dbmodel.CPRelation
+	err := dbu.client.WithContext(ctx).
+		Where("(stu_id = ? OR partner_stu_id = ?) AND state IN ?", stuId, stuId,
+			[]string{dbmodel.CPStateInvited, dbmodel.CPStateAccepted}).
+		First(&cp).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &cp, nil
+}
+
+// AcceptCPInvite 把 invited 状态流转为 accepted 并写入 bound_at。
+func (dbu *DBUser) AcceptCPInvite(ctx context.Context, id int64) error {
+	now := time.Now()
+	res := dbu.client.WithContext(ctx).Model(&dbmodel.CPRelation{}).
+		Where("id = ? AND state = ?", id, dbmodel.CPStateInvited).
+		Updates(map[string]interface{}{"state": dbmodel.CPStateAccepted, "bound_at": now})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// CancelCPInvite 把一条还没被 Accept 的邀请置为 cancelled。
+func (dbu *DBUser) CancelCPInvite(ctx context.Context, id int64) error {
+	res := dbu.client.WithContext(ctx).Model(&dbmodel.CPRelation{}).
+		Where("id = ? AND state = ?", id, dbmodel.CPStateInvited).
+		Update("state", dbmodel.CPStateCancelled)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// BreakCPRelation 把一段 accepted 状态的 CP 置为 broken，不会影响底层的好友关系。
+func (dbu *DBUser) BreakCPRelation(ctx context.Context, id int64) error {
+	res := dbu.client.WithContext(ctx).Model(&dbmodel.CPRelation{}).
+		Where("id = ? AND state = ?", id, dbmodel.CPStateAccepted).
+		Update("state", dbmodel.CPStateBroken)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2024 The west2-online Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package user
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+
+	dbmodel "github.com/west2-online/fzuhelper-server/pkg/db/model"
+)
+
+// ErrFriendRequestNotPending 表示目标好友申请已经被处理过（或不存在），不能再次 Accept/Reject。
+var ErrFriendRequestNotPending = errors.New("friend request is not pending")
+
+// CreateFriendRequest 写入一条待处理的好友申请。
+func (dbu *DBUser) CreateFriendRequest(ctx context.Context, fromStuId, toStuId, message string) (*dbmodel.FriendRequest, error) {
+	req := &dbmodel.FriendRequest{
+		FromStuId: fromStuId,
+		ToStuId:   toStuId,
+		Message:   message,
+		State:     dbmodel.FriendRequestStatePending,
+	}
+	if err := dbu.client.WithContext(ctx).Create(req).Error; err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// GetFriendRequestById 查询好友申请，供 Accept/Reject 校验状态与归属方使用。
+func (dbu *DBUser) GetFriendRequestById(ctx context.Context, id int64) (*dbmodel.FriendRequest, error) {
+	var req dbmodel.FriendRequest
+	if err := dbu.client.WithContext(ctx).Where("id = ?", id).First(&req).Error; err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// UpdateFriendRequestState 将好友申请置为 accepted 或 rejected，只允许从 pending 流转。
+func (dbu *DBUser) UpdateFriendRequestState(ctx context.Context, id int64, state string) error {
+	res := dbu.client.WithContext(ctx).Model(&dbmodel.FriendRequest{}).
+		Where("id = ? AND state = ?", id, dbmodel.FriendRequestStatePending).
+		Update("state", state)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrFriendRequestNotPending
+	}
+	return nil
+}
+
+// AcceptFriendRequestWithOutbox 在同一个事务里把好友申请从 pending 流转为 accepted、做屏蔽检查并写入
+// 好友关系和对应的 relation_events 记录，避免状态流转和关系落地分成两步导致 accepted 之后关系却没建成的悬挂态。
+func (dbu *DBUser) AcceptFriendRequestWithOutbox(ctx context.Context, requestId int64, fromStuId, toStuId string) error {
+	return dbu.client.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&dbmodel.FriendRequest{}).
+			Where("id = ? AND state = ?", requestId, dbmodel.FriendRequestStatePending).
+			Update("state", dbmodel.FriendRequestStateAccepted)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrFriendRequestNotPending
+		}
+		if err := createRelationTx(tx, fromStuId, toStuId); err != nil {
+			return err
+		}
+		event := &dbmodel.RelationEvent{StuId: fromStuId, FriendId: toStuId}
+		return tx.Create(event).Error
+	})
+}
+
+// ListPendingRequests 列出发给某个学号的所有待处理好友申请。
+func (dbu *DBUser) ListPendingRequests(ctx context.Context, stuId string) ([]*dbmodel.FriendRequest, error) {
+	var reqs []*dbmodel.FriendRequest
+	err := dbu.client.WithContext(ctx).
+		Where("to_stu_id = ? AND state = ?", stuId, dbmodel.FriendRequestStatePending).
+		Find(&reqs).Error
+	if err != nil {
+		return nil, err
+	}
+	return reqs, nil
+}
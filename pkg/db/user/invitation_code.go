@@ -0,0 +1,112 @@
+/*
+Copyright 2024 The west2-online Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package user
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+
+	dbmodel "github.com/west2-online/fzuhelper-server/pkg/db/model"
+)
+
+// CreateInvitationCode 插入一条邀请码记录，返回带有自增主键的记录，调用方会将其 ID 编码为对外的 HashID。
+func (dbu *DBUser) CreateInvitationCode(ctx context.Context, ownerStuId string, ttl time.Duration, maxUses int64, autoAccept bool) (*dbmodel.InvitationCode, error) {
+	code := &dbmodel.InvitationCode{
+		OwnerStuId:    ownerStuId,
+		RemainingUses: maxUses,
+		ExpiresAt:     time.Now().Add(ttl),
+		AutoAccept:    autoAccept,
+	}
+	if err := dbu.client.WithContext(ctx).Create(code).Error; err != nil {
+		return nil, err
+	}
+	return code, nil
+}
+
+// GetInvitationCodeById 根据 HashID 解码出的自增主键查询邀请码元数据。
+func (dbu *DBUser) GetInvitationCodeById(ctx context.Context, id int64) (*dbmodel.InvitationCode, error) {
+	var code dbmodel.InvitationCode
+	err := dbu.client.WithContext(ctx).Where("id = ?", id).First(&code).Error
+	if err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
+
+// consumeInvitationCodeTx 在给定事务里原子地扣减剩余可用次数，避免并发绑定时多扣或扣成负数。
+// 调用方必须在邀请码真正被用掉（关系或好友申请已经落地）之后才调用它，否则一次事务失败
+// 就会白白烧掉一次使用次数。
+func consumeInvitationCodeTx(tx *gorm.DB, id int64) (remaining int64, err error) {
+	res := tx.Model(&dbmodel.InvitationCode{}).
+		Where("id = ? AND remaining_uses > 0", id).
+		UpdateColumn("remaining_uses", gorm.Expr("remaining_uses - 1"))
+	if res.Error != nil {
+		return 0, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return 0, gorm.ErrRecordNotFound
+	}
+	var code dbmodel.InvitationCode
+	if err := tx.Where("id = ?", id).First(&code).Error; err != nil {
+		return 0, err
+	}
+	return code.RemainingUses, nil
+}
+
+// CreateFriendRequestAndConsume 在同一个事务里做屏蔽检查、写入待处理的好友申请、并扣减邀请码剩余
+// 次数；只有在好友申请真正落地之后才会消费邀请码的使用次数，避免事务失败时邀请码被白白烧掉。
+func (dbu *DBUser) CreateFriendRequestAndConsume(ctx context.Context, fromStuId, toStuId, message string, codeId int64) (req *dbmodel.FriendRequest, remaining int64, err error) {
+	err = dbu.client.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		blockErr := tx.Model(&dbmodel.UserBlock{}).
+			Where("(stu_id = ? AND blocked_stu_id = ?) OR (stu_id = ? AND blocked_stu_id = ?)",
+				fromStuId, toStuId, toStuId, fromStuId).
+			Count(&count).Error
+		if blockErr != nil {
+			return blockErr
+		}
+		if count > 0 {
+			return ErrBlocked
+		}
+
+		r := &dbmodel.FriendRequest{
+			FromStuId: fromStuId,
+			ToStuId:   toStuId,
+			Message:   message,
+			State:     dbmodel.FriendRequestStatePending,
+		}
+		if err := tx.Create(r).Error; err != nil {
+			return err
+		}
+		req = r
+
+		rem, err := consumeInvitationCodeTx(tx, codeId)
+		if err != nil {
+			return err
+		}
+		remaining = rem
+		return nil
+	})
+	return req, remaining, err
+}
+
+// DeleteInvitationCode 删除邀请码的元数据，BindInvitation 在剩余次数归零后调用；过期的邀请码不会被自动删除。
+func (dbu *DBUser) DeleteInvitationCode(ctx context.Context, id int64) error {
+	return dbu.client.WithContext(ctx).Delete(&dbmodel.InvitationCode{}, id).Error
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2024 The west2-online Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package user
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	dbmodel "github.com/west2-online/fzuhelper-server/pkg/db/model"
+)
+
+// ErrBlocked 表示 stuId、friendId 之间存在屏蔽关系，事务内的关系创建因此被拒绝。
+var ErrBlocked = errors.New("blocked")
+
+// createRelationTx 在给定事务里做屏蔽检查、确认关系尚未存在、再写入好友关系，供 CreateRelationWithOutbox
+// 及其携带邀请码消费的变体、以及 AcceptFriendRequestWithOutbox 复用，保证检查和关系落地是同一个事务、
+// 不会有竞态窗口。stuId、friendId 之间如果已经通过别的渠道（比如邀请码）建立了关系，这里直接当成
+// no-op 成功返回，不会插入第二条重复的 FollowRelation。
+func createRelationTx(tx *gorm.DB, stuId, friendId string) error {
+	var blockCount int64
+	err := tx.Model(&dbmodel.UserBlock{}).
+		Where("(stu_id = ? AND blocked_stu_id = ?) OR (stu_id = ? AND blocked_stu_id = ?)",
+			stuId, friendId, friendId, stuId).
+		Count(&blockCount).Error
+	if err != nil {
+		return err
+	}
+	if blockCount > 0 {
+		return ErrBlocked
+	}
+
+	var relationCount int64
+	err = tx.Model(&dbmodel.FollowRelation{}).
+		Where("(stu_id = ? AND friend_id = ?) OR (stu_id = ? AND friend_id = ?)",
+			stuId, friendId, friendId, stuId).
+		Count(&relationCount).Error
+	if err != nil {
+		return err
+	}
+	if relationCount > 0 {
+		return nil
+	}
+
+	return tx.Create(&dbmodel.FollowRelation{StuId: stuId, FriendId: friendId}).Error
+}
+
+// CreateRelationWithOutbox 在同一个事务里做屏蔽检查、写入好友关系和对应的 relation_events 记录，
+// codeMappingKey 非空时表示邀请码已经用尽，dispatcher 需要在回填缓存之后把这条映射也删掉。
+func (dbu *DBUser) CreateRelationWithOutbox(ctx context.Context, stuId, friendId, codeMappingKey string) error {
+	return dbu.client.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := createRelationTx(tx, stuId, friendId); err != nil {
+			return err
+		}
+		event := &dbmodel.RelationEvent{
+			StuId:          stuId,
+			FriendId:       friendId,
+			CodeMappingKey: codeMappingKey,
+		}
+		return tx.Create(event).Error
+	})
+}
+
+// CreateRelationWithOutboxAndConsume 在同一个事务里做屏蔽检查、写入好友关系、扣减邀请码剩余次数、
+// 并写入 outbox 记录；只有在关系真正落地之后才会消费邀请码的使用次数，避免事务失败时邀请码被白白烧掉。
+// mapKey 只有在扣减后 remaining_uses 归零时才会被写进 outbox 记录，交给 dispatcher 清理缓存映射。
+func (dbu *DBUser) CreateRelationWithOutboxAndConsume(ctx context.Context, stuId, friendId string, codeId int64, mapKey string) (remaining int64, err error) {
+	err = dbu.client.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := createRelationTx(tx, stuId, friendId); err != nil {
+			return err
+		}
+		r, err := consumeInvitationCodeTx(tx, codeId)
+		if err != nil {
+			return err
+		}
+		remaining = r
+
+		codeMappingKey := ""
+		if remaining == 0 {
+			codeMappingKey = mapKey
+		}
+		event := &dbmodel.RelationEvent{
+			StuId:          stuId,
+			FriendId:       friendId,
+			CodeMappingKey: codeMappingKey,
+		}
+		return tx.Create(event).Error
+	})
+	return remaining, err
+}
+
+// ListUnprocessedRelationEvents 返回所有尚未被 dispatcher 处理过的 outbox 记录，用于启动时重放。
+func (dbu *DBUser) ListUnprocessedRelationEvents(ctx context.Context) ([]*dbmodel.RelationEvent, error) {
+	var events []*dbmodel.RelationEvent
+	err := dbu.client.WithContext(ctx).Where("processed = ?", false).Find(&events).Error
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// MarkRelationEventProcessed 把一条 outbox 记录标记为已处理。
+func (dbu *DBUser) MarkRelationEventProcessed(ctx context.Context, id int64) error {
+	now := time.Now()
+	return dbu.client.WithContext(ctx).Model(&dbmodel.RelationEvent{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{"processed": true, "processed_at": now}).Error
+}
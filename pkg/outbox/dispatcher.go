@@ -0,0 +1,94 @@
+/*
+Copyright 2024 The west2-online Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package outbox 实现好友关系事件的事务性 outbox 分发器：CreateRelationWithOutbox 已经把
+// relation_events 行和好友关系写在同一个 DB 事务里，Dispatcher 只负责把这些行异步地
+// 应用到缓存上，即使进程重启也能靠重放把缓存最终补齐，不会再像之前的 fire-and-forget
+// goroutine 那样在进程死掉时静默丢失。
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/west2-online/fzuhelper-server/pkg/cache"
+	"github.com/west2-online/fzuhelper-server/pkg/db"
+	dbmodel "github.com/west2-online/fzuhelper-server/pkg/db/model"
+	"github.com/west2-online/fzuhelper-server/pkg/logger"
+)
+
+const defaultPollInterval = 2 * time.Second
+
+type Dispatcher struct {
+	db           *db.Database
+	cache        *cache.Cache
+	pollInterval time.Duration
+}
+
+func NewDispatcher(dbClient *db.Database, cacheClient *cache.Cache) *Dispatcher {
+	return &Dispatcher{
+		db:           dbClient,
+		cache:        cacheClient,
+		pollInterval: defaultPollInterval,
+	}
+}
+
+// Start 在启动时先重放一遍未处理的记录，然后按固定间隔轮询新记录，直到 ctx 被取消。
+func (d *Dispatcher) Start(ctx context.Context) {
+	d.dispatchOnce(ctx)
+
+	ticker := time.NewTicker(d.pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.dispatchOnce(ctx)
+		}
+	}
+}
+
+func (d *Dispatcher) dispatchOnce(ctx context.Context) {
+	events, err := d.db.User.ListUnprocessedRelationEvents(ctx)
+	if err != nil {
+		logger.Errorf("outbox.ListUnprocessedRelationEvents: %v", err)
+		return
+	}
+	for _, event := range events {
+		d.apply(ctx, event)
+	}
+}
+
+func (d *Dispatcher) apply(ctx context.Context, event *dbmodel.RelationEvent) {
+	if err := d.cache.User.SetUserFriendCache(ctx, event.StuId, event.FriendId); err != nil {
+		logger.Errorf("outbox.SetUserFriendCache: %v", err)
+		return
+	}
+	if err := d.cache.User.SetUserFriendCache(ctx, event.FriendId, event.StuId); err != nil {
+		logger.Errorf("outbox.SetUserFriendCache: %v", err)
+		return
+	}
+	if event.CodeMappingKey != "" {
+		if err := d.cache.User.RemoveCodeStuIdMappingCache(ctx, event.CodeMappingKey); err != nil {
+			logger.Errorf("outbox.RemoveCodeStuIdMappingCache: %v", err)
+			return
+		}
+	}
+	if err := d.db.User.MarkRelationEventProcessed(ctx, event.ID); err != nil {
+		logger.Errorf("outbox.MarkRelationEventProcessed: %v", err)
+	}
+}
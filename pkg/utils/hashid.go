@@ -0,0 +1,62 @@
+/*
+Copyright 2024 The west2-online Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"fmt"
+
+	"github.com/speps/go-hashids/v2"
+)
+
+// invitationHashIdSalt 仅用于混淆邀请码自增 ID，不作为安全凭证使用。
+const invitationHashIdSalt = "fzuhelper-invitation"
+
+func newInvitationHashId() (*hashids.HashID, error) {
+	hd := hashids.NewData()
+	hd.Salt = invitationHashIdSalt
+	hd.MinLength = 6
+	return hashids.NewWithData(hd)
+}
+
+// EncodeInvitationId 将邀请码的自增 ID 编码为对外可见的不透明字符串。
+func EncodeInvitationId(id int64) (string, error) {
+	h, err := newInvitationHashId()
+	if err != nil {
+		return "", fmt.Errorf("utils.EncodeInvitationId: %w", err)
+	}
+	code, err := h.EncodeInt64([]int64{id})
+	if err != nil {
+		return "", fmt.Errorf("utils.EncodeInvitationId: %w", err)
+	}
+	return code, nil
+}
+
+// DecodeInvitationId 将邀请码还原为自增 ID，非法格式会返回 error。
+func DecodeInvitationId(code string) (int64, error) {
+	h, err := newInvitationHashId()
+	if err != nil {
+		return 0, fmt.Errorf("utils.DecodeInvitationId: %w", err)
+	}
+	ids, err := h.DecodeInt64WithError(code)
+	if err != nil {
+		return 0, fmt.Errorf("utils.DecodeInvitationId: %w", err)
+	}
+	if len(ids) != 1 {
+		return 0, fmt.Errorf("utils.DecodeInvitationId: invalid invitation code")
+	}
+	return ids[0], nil
+}